@@ -0,0 +1,93 @@
+// Package cache memoizes the filtered records produced by a
+// parser.FixedWidthReader, so that repeated runs over the same data
+// file, layout, and variable selection skip the fixed-width scan
+// entirely.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kshedden/MEPS-weights/parser"
+)
+
+// Store reads and writes cached records under Dir, one gob file per
+// cache key.
+type Store struct {
+	Dir string
+}
+
+// NewStore returns a Store backed by dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Key derives a cache key from the data file's checksum, the SAS
+// layout's checksum, and the set of variables selected out of each
+// record. Any change to the raw data, the layout, or which variables
+// are being extracted invalidates the cache.
+func Key(dataChecksum, layoutChecksum string, vars []string) string {
+	sorted := append([]string{}, vars...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	h.Write([]byte(dataChecksum))
+	h.Write([]byte{0})
+	h.Write([]byte(layoutChecksum))
+	h.Write([]byte{0})
+	h.Write([]byte(strings.Join(sorted, ",")))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *Store) path(key string) string {
+	return filepath.Join(s.Dir, key+".gob")
+}
+
+// Load returns the cached records for key, or ok=false if no cache
+// entry exists yet.
+func (s *Store) Load(key string) (recs []parser.Record, ok bool, err error) {
+	fid, err := os.Open(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer fid.Close()
+
+	if err := gob.NewDecoder(fid).Decode(&recs); err != nil {
+		return nil, false, err
+	}
+	return recs, true, nil
+}
+
+// Save writes recs to the cache under key, replacing any existing
+// entry.
+func (s *Store) Save(key string, recs []parser.Record) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.Dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(recs); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path(key))
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kshedden/MEPS-weights/parser"
+)
+
+func TestKeyIsOrderIndependentOverVars(t *testing.T) {
+	k1 := Key("datasum", "layoutsum", []string{"A", "B"})
+	k2 := Key("datasum", "layoutsum", []string{"B", "A"})
+	if k1 != k2 {
+		t.Errorf("Key with reordered vars = %q, %q, want equal", k1, k2)
+	}
+}
+
+func TestKeyChangesWithInputs(t *testing.T) {
+	base := Key("datasum", "layoutsum", []string{"A", "B"})
+	cases := map[string]string{
+		"datasum2":   Key("datasum2", "layoutsum", []string{"A", "B"}),
+		"layoutsum2": Key("datasum", "layoutsum2", []string{"A", "B"}),
+		"vars":       Key("datasum", "layoutsum", []string{"A", "C"}),
+	}
+	for name, k := range cases {
+		if k == base {
+			t.Errorf("Key changed with %s did not change from base", name)
+		}
+	}
+}
+
+func TestStoreLoadMissing(t *testing.T) {
+	s := NewStore(t.TempDir())
+	_, ok, err := s.Load("nonexistent")
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if ok {
+		t.Error("Load for a missing key reported ok=true")
+	}
+}
+
+func TestStoreSaveLoadRoundTrip(t *testing.T) {
+	s := NewStore(filepath.Join(t.TempDir(), "nested", "cache"))
+	key := Key("datasum", "layoutsum", []string{"A", "B"})
+
+	want := []parser.Record{
+		{"A": 1, "B": 2},
+		{"A": 3, "B": 4},
+	}
+	if err := s.Save(key, want); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+
+	got, ok, err := s.Load(key)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !ok {
+		t.Fatal("Load after Save reported ok=false")
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Load returned %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		for k, v := range want[i] {
+			if got[i][k] != v {
+				t.Errorf("record %d: %s = %v, want %v", i, k, got[i][k], v)
+			}
+		}
+	}
+}
+
+func TestStoreSaveOverwritesExistingEntry(t *testing.T) {
+	s := NewStore(t.TempDir())
+	key := Key("datasum", "layoutsum", []string{"A"})
+
+	if err := s.Save(key, []parser.Record{{"A": 1}}); err != nil {
+		t.Fatalf("first Save returned error: %v", err)
+	}
+	if err := s.Save(key, []parser.Record{{"A": 2}}); err != nil {
+		t.Fatalf("second Save returned error: %v", err)
+	}
+
+	got, ok, err := s.Load(key)
+	if err != nil || !ok {
+		t.Fatalf("Load after overwrite = (ok=%v, err=%v)", ok, err)
+	}
+	if len(got) != 1 || got[0]["A"] != 2 {
+		t.Errorf("Load after overwrite = %v, want [{A:2}]", got)
+	}
+}
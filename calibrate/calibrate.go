@@ -0,0 +1,190 @@
+// Package calibrate adjusts per-stratum survey weights to match
+// external control totals (e.g. Census CPS/ACS marginals), using
+// iterative proportional fitting (raking) or direct post-stratification
+// against joint cell targets.
+package calibrate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Margin describes one raking dimension: a partition of the cells
+// into named levels (e.g. "female"/"male"), each with its own target
+// total.
+type Margin struct {
+	Name    string             `json:"name"`
+	Levels  map[string][]int   `json:"levels"`
+	Targets map[string]float64 `json:"targets"`
+}
+
+// RakeSpec configures iterative proportional fitting over a set of
+// margins.
+type RakeSpec struct {
+	Margins []Margin `json:"margins"`
+
+	// MaxIter bounds the number of passes over all margins.
+	MaxIter int `json:"max_iter"`
+
+	// Tol is the convergence threshold: iteration stops once every
+	// level's relative adjustment in a full pass is below Tol.
+	Tol float64 `json:"tol"`
+}
+
+// RakeResult holds the outcome of a call to Rake.
+type RakeResult struct {
+	Weights    []float64
+	Iterations int
+	Converged  bool
+}
+
+// Rake rescales a copy of weights so that, within each of spec's
+// margins, the weights belonging to each level sum to that level's
+// target. Margins are cycled in order, repeatedly, until the largest
+// relative change in any level's total over a full pass falls below
+// spec.Tol or spec.MaxIter passes have run.
+func Rake(weights []float64, spec RakeSpec) (RakeResult, error) {
+	w := append([]float64{}, weights...)
+
+	for _, m := range spec.Margins {
+		for level, cells := range m.Levels {
+			if _, ok := m.Targets[level]; !ok {
+				return RakeResult{}, fmt.Errorf("margin %q: no target for level %q", m.Name, level)
+			}
+			for _, c := range cells {
+				if c < 0 || c >= len(w) {
+					return RakeResult{}, fmt.Errorf("margin %q level %q: cell %d out of range [0, %d)", m.Name, level, c, len(w))
+				}
+			}
+		}
+	}
+
+	converged := false
+	iter := 0
+	for ; iter < spec.MaxIter; iter++ {
+		maxRelChange := 0.0
+
+		for _, m := range spec.Margins {
+			for level, cells := range m.Levels {
+				var sum float64
+				for _, c := range cells {
+					sum += w[c]
+				}
+				if sum == 0 {
+					if m.Targets[level] != 0 {
+						return RakeResult{}, fmt.Errorf("margin %q level %q has zero weight but a nonzero target %v",
+							m.Name, level, m.Targets[level])
+					}
+					continue
+				}
+
+				factor := m.Targets[level] / sum
+				if rel := math.Abs(factor - 1); rel > maxRelChange {
+					maxRelChange = rel
+				}
+				for _, c := range cells {
+					w[c] *= factor
+				}
+			}
+		}
+
+		if maxRelChange < spec.Tol {
+			converged = true
+			iter++
+			break
+		}
+	}
+
+	return RakeResult{Weights: w, Iterations: iter, Converged: converged}, nil
+}
+
+// PostStratify rescales a copy of weights so that each cell named in
+// targets sums exactly to its target total. Unlike raking, this is a
+// single pass: post-stratification cells are assumed to partition the
+// population, so each cell's target is independent of the others.
+func PostStratify(weights []float64, targets map[int]float64) ([]float64, error) {
+	w := append([]float64{}, weights...)
+
+	for cell, target := range targets {
+		if cell < 0 || cell >= len(w) {
+			return nil, fmt.Errorf("cell %d out of range [0, %d)", cell, len(w))
+		}
+		if w[cell] == 0 {
+			if target != 0 {
+				return nil, fmt.Errorf("cell %d has zero weight but a nonzero target %v", cell, target)
+			}
+			continue
+		}
+		w[cell] *= target / w[cell]
+	}
+
+	return w, nil
+}
+
+// Adjustment records how much one cell's weight moved during
+// calibration.
+type Adjustment struct {
+	Cell       int
+	Original   float64
+	Calibrated float64
+	Ratio      float64 // Calibrated / Original
+}
+
+// Report summarizes the effect of a calibration: the Kish design
+// effect inflation introduced by the now-unequal weights, and the
+// largest per-cell adjustments.
+type Report struct {
+	// DesignEffect is Kish's design effect, n * sum(w^2) / sum(w)^2,
+	// computed on the calibrated weights. A value of 1 means the
+	// calibrated weights are still effectively equal; larger values
+	// mean calibration has inflated the variance of estimates that
+	// use these weights.
+	DesignEffect float64
+
+	// LargestAdjustments lists the cells with the biggest |ratio-1|,
+	// largest first.
+	LargestAdjustments []Adjustment
+}
+
+// Diagnose compares original and calibrated weights and returns a
+// Report with the topN largest adjustments. It returns an error if
+// topN is negative.
+func Diagnose(original, calibrated []float64, topN int) (Report, error) {
+	if topN < 0 {
+		return Report{}, fmt.Errorf("topN must be non-negative, got %d", topN)
+	}
+
+	n := len(calibrated)
+
+	var sum, sumSq float64
+	for _, w := range calibrated {
+		sum += w
+		sumSq += w * w
+	}
+	var deff float64
+	if sum > 0 {
+		deff = float64(n) * sumSq / (sum * sum)
+	}
+
+	adjustments := make([]Adjustment, 0, n)
+	for i := range calibrated {
+		if original[i] == 0 {
+			continue
+		}
+		adjustments = append(adjustments, Adjustment{
+			Cell:       i,
+			Original:   original[i],
+			Calibrated: calibrated[i],
+			Ratio:      calibrated[i] / original[i],
+		})
+	}
+	sort.Slice(adjustments, func(i, j int) bool {
+		return math.Abs(adjustments[i].Ratio-1) > math.Abs(adjustments[j].Ratio-1)
+	})
+	if len(adjustments) > topN {
+		adjustments = adjustments[:topN]
+	}
+
+	return Report{DesignEffect: deff, LargestAdjustments: adjustments}, nil
+}
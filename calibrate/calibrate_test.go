@@ -0,0 +1,158 @@
+package calibrate
+
+import (
+	"math"
+	"testing"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestRakeSingleMarginConverges(t *testing.T) {
+	weights := []float64{10, 20, 30, 40}
+	spec := RakeSpec{
+		Margins: []Margin{
+			{
+				Name:    "grp",
+				Levels:  map[string][]int{"A": {0, 1}, "B": {2, 3}},
+				Targets: map[string]float64{"A": 60, "B": 140},
+			},
+		},
+		MaxIter: 50,
+		Tol:     1e-4,
+	}
+
+	result, err := Rake(weights, spec)
+	if err != nil {
+		t.Fatalf("Rake returned error: %v", err)
+	}
+	if !result.Converged {
+		t.Fatalf("Rake did not converge within %d iterations", spec.MaxIter)
+	}
+
+	want := []float64{20, 40, 60, 80} // scales A (10+20=30 -> 60) and B (30+40=70 -> 140) by 2x
+	for i, w := range result.Weights {
+		if !approxEqual(w, want[i]) {
+			t.Errorf("Weights[%d] = %v, want %v", i, w, want[i])
+		}
+	}
+
+	// Original input must not be mutated.
+	if weights[0] != 10 {
+		t.Errorf("Rake mutated its input: weights[0] = %v, want 10", weights[0])
+	}
+}
+
+func TestRakeOutOfRangeCell(t *testing.T) {
+	weights := []float64{10, 20}
+	spec := RakeSpec{
+		Margins: []Margin{
+			{Name: "grp", Levels: map[string][]int{"A": {5}}, Targets: map[string]float64{"A": 10}},
+		},
+		MaxIter: 10,
+		Tol:     1e-4,
+	}
+	if _, err := Rake(weights, spec); err == nil {
+		t.Fatal("Rake with out-of-range cell index did not return an error")
+	}
+}
+
+func TestRakeZeroWeightNonzeroTarget(t *testing.T) {
+	weights := []float64{0, 0, 30, 40}
+	spec := RakeSpec{
+		Margins: []Margin{
+			{Name: "grp", Levels: map[string][]int{"A": {0, 1}}, Targets: map[string]float64{"A": 60}},
+		},
+		MaxIter: 10,
+		Tol:     1e-4,
+	}
+	if _, err := Rake(weights, spec); err == nil {
+		t.Fatal("Rake with zero weight and nonzero target did not return an error")
+	}
+}
+
+func TestRakeZeroWeightZeroTargetSkipped(t *testing.T) {
+	weights := []float64{0, 0, 30, 40}
+	spec := RakeSpec{
+		Margins: []Margin{
+			{Name: "grp", Levels: map[string][]int{"A": {0, 1}}, Targets: map[string]float64{"A": 0}},
+		},
+		MaxIter: 10,
+		Tol:     1e-4,
+	}
+	result, err := Rake(weights, spec)
+	if err != nil {
+		t.Fatalf("Rake returned error: %v", err)
+	}
+	if result.Weights[0] != 0 || result.Weights[1] != 0 {
+		t.Errorf("Weights = %v, want [0, 0, ...]", result.Weights)
+	}
+}
+
+func TestPostStratify(t *testing.T) {
+	weights := []float64{10, 20, 30}
+	calibrated, err := PostStratify(weights, map[int]float64{0: 5, 2: 60})
+	if err != nil {
+		t.Fatalf("PostStratify returned error: %v", err)
+	}
+
+	want := []float64{5, 20, 60}
+	for i, w := range calibrated {
+		if !approxEqual(w, want[i]) {
+			t.Errorf("calibrated[%d] = %v, want %v", i, w, want[i])
+		}
+	}
+}
+
+func TestPostStratifyOutOfRange(t *testing.T) {
+	if _, err := PostStratify([]float64{10, 20}, map[int]float64{5: 1}); err == nil {
+		t.Fatal("PostStratify with out-of-range cell did not return an error")
+	}
+}
+
+func TestDiagnose(t *testing.T) {
+	original := []float64{100, 100, 100, 100}
+	calibrated := []float64{100, 100, 100, 100}
+	report, err := Diagnose(original, calibrated, 10)
+	if err != nil {
+		t.Fatalf("Diagnose returned error: %v", err)
+	}
+
+	// Equal weights have a Kish design effect of exactly 1.
+	if !approxEqual(report.DesignEffect, 1) {
+		t.Errorf("DesignEffect = %v, want 1", report.DesignEffect)
+	}
+	if len(report.LargestAdjustments) != 4 {
+		t.Fatalf("len(LargestAdjustments) = %d, want 4", len(report.LargestAdjustments))
+	}
+	for _, a := range report.LargestAdjustments {
+		if !approxEqual(a.Ratio, 1) {
+			t.Errorf("Ratio = %v, want 1", a.Ratio)
+		}
+	}
+}
+
+func TestDiagnoseOrdersLargestAdjustmentsFirst(t *testing.T) {
+	original := []float64{100, 100, 100}
+	calibrated := []float64{100, 150, 90} // ratios: 1.0, 1.5, 0.9
+	report, err := Diagnose(original, calibrated, 1)
+	if err != nil {
+		t.Fatalf("Diagnose returned error: %v", err)
+	}
+
+	if len(report.LargestAdjustments) != 1 {
+		t.Fatalf("len(LargestAdjustments) = %d, want 1", len(report.LargestAdjustments))
+	}
+	if report.LargestAdjustments[0].Cell != 1 {
+		t.Errorf("LargestAdjustments[0].Cell = %d, want 1 (ratio 1.5 is the biggest departure from 1)", report.LargestAdjustments[0].Cell)
+	}
+}
+
+func TestDiagnoseNegativeTopN(t *testing.T) {
+	original := []float64{100, 100}
+	calibrated := []float64{100, 100}
+	if _, err := Diagnose(original, calibrated, -1); err == nil {
+		t.Error("Diagnose with negative topN did not return an error")
+	}
+}
@@ -0,0 +1,85 @@
+package calibrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Method selects how Config's targets are applied to the design
+// weights.
+type Method string
+
+const (
+	// MethodRake applies iterative proportional fitting to Margins.
+	MethodRake Method = "rake"
+	// MethodPostStratify applies CellTargets directly, one pass.
+	MethodPostStratify Method = "poststratify"
+)
+
+// Config describes one calibration run: which year's ESI totals to
+// calibrate, against which external control totals, and how.
+type Config struct {
+	Year   int    `json:"year"`
+	Method Method `json:"method"`
+
+	// Margins is used when Method is MethodRake.
+	Margins []Margin `json:"margins"`
+
+	// CellTargets is used when Method is MethodPostStratify; keys are
+	// stratum indices as strings, since JSON object keys must be
+	// strings.
+	CellTargets map[string]float64 `json:"cell_targets"`
+
+	MaxIter int     `json:"max_iter"`
+	Tol     float64 `json:"tol"`
+}
+
+// LoadConfig reads and validates the calibration config at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	switch c.Method {
+	case MethodRake:
+		if len(c.Margins) == 0 {
+			return nil, fmt.Errorf("%s: method %q requires at least one margin", path, c.Method)
+		}
+		if c.MaxIter == 0 {
+			c.MaxIter = 50
+		}
+		if c.Tol == 0 {
+			c.Tol = 1e-4
+		}
+	case MethodPostStratify:
+		if len(c.CellTargets) == 0 {
+			return nil, fmt.Errorf("%s: method %q requires cell_targets", path, c.Method)
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown method %q, want %q or %q", path, c.Method, MethodRake, MethodPostStratify)
+	}
+
+	return &c, nil
+}
+
+// CellTargetsByIndex converts CellTargets' string keys to stratum
+// indices.
+func (c *Config) CellTargetsByIndex() (map[int]float64, error) {
+	out := make(map[int]float64, len(c.CellTargets))
+	for k, v := range c.CellTargets {
+		idx, err := strconv.Atoi(k)
+		if err != nil {
+			return nil, fmt.Errorf("cell_targets key %q is not an integer stratum index", k)
+		}
+		out[idx] = v
+	}
+	return out, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/kshedden/MEPS-weights/calibrate"
+)
+
+var (
+	calibrateCmd    = flag.NewFlagSet("calibrate", flag.ExitOnError)
+	calibrateConfig = calibrateCmd.String("config", "", "path to a calibration config JSON file (required)")
+	calibrateTotals = calibrateCmd.String("totals", "meps_totals.csv", "path to the meps_totals.csv produced by the totals subcommand")
+	calibrateOut    = calibrateCmd.String("out", "meps_calibrated.csv", "path to write calibrated weights to")
+	calibrateTopN   = calibrateCmd.Int("top-n", 10, "number of largest weight adjustments to report")
+)
+
+// runCalibrate rakes or post-stratifies one year's ESI totals against
+// the control totals described by -config, and writes the calibrated
+// weights plus a diagnostic report.
+func runCalibrate(args []string) {
+	calibrateCmd.Parse(args)
+
+	if *calibrateConfig == "" {
+		fmt.Fprintln(os.Stderr, "calibrate: -config is required")
+		os.Exit(2)
+	}
+
+	cfg, err := calibrate.LoadConfig(*calibrateConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	years, totals, err := readTotalsCSV(*calibrateTotals)
+	if err != nil {
+		panic(err)
+	}
+
+	original, ok := totals[cfg.Year]
+	if !ok {
+		panic(fmt.Sprintf("year %d not found in %s (years present: %v)", cfg.Year, *calibrateTotals, years))
+	}
+
+	var calibrated []float64
+	switch cfg.Method {
+	case calibrate.MethodRake:
+		result, err := calibrate.Rake(original, calibrate.RakeSpec{
+			Margins: cfg.Margins,
+			MaxIter: cfg.MaxIter,
+			Tol:     cfg.Tol,
+		})
+		if err != nil {
+			panic(err)
+		}
+		if !result.Converged {
+			fmt.Fprintf(os.Stderr, "calibrate: raking did not converge within %d iterations\n", cfg.MaxIter)
+		}
+		calibrated = result.Weights
+	case calibrate.MethodPostStratify:
+		targets, err := cfg.CellTargetsByIndex()
+		if err != nil {
+			panic(err)
+		}
+		calibrated, err = calibrate.PostStratify(original, targets)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	if err := writeCalibrated(*calibrateOut, original, calibrated); err != nil {
+		panic(err)
+	}
+
+	report, err := calibrate.Diagnose(original, calibrated, *calibrateTopN)
+	if err != nil {
+		panic(err)
+	}
+	printReport(report)
+}
+
+// readTotalsCSV reads the wide-format CSV written by writeTotals: a
+// header row of years, followed by 33 rows of per-stratum totals.
+func readTotalsCSV(path string) (years []int, totals map[int][]float64, error error) {
+	fid, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fid.Close()
+
+	rdr := csv.NewReader(fid)
+	rows, err := rdr.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) < 2 {
+		return nil, nil, fmt.Errorf("%s: expected a header row and at least one data row", path)
+	}
+
+	header := rows[0]
+	years = make([]int, len(header))
+	totals = make(map[int][]float64, len(header))
+	for j, h := range header {
+		y, err := strconv.Atoi(h)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: bad year header %q: %w", path, h, err)
+		}
+		years[j] = y
+		totals[y] = make([]float64, len(rows)-1)
+	}
+
+	for i, row := range rows[1:] {
+		if len(row) != len(header) {
+			return nil, nil, fmt.Errorf("%s: row %d has %d columns, want %d", path, i+2, len(row), len(header))
+		}
+		for j, cell := range row {
+			v, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: row %d: %w", path, i+2, err)
+			}
+			totals[years[j]][i] = v
+		}
+	}
+
+	return years, totals, nil
+}
+
+// writeCalibrated writes one row per stratum with its original
+// weight, calibrated weight, and their ratio.
+func writeCalibrated(path string, original, calibrated []float64) error {
+	fid, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fid.Close()
+
+	wtr := csv.NewWriter(fid)
+	if err := wtr.Write([]string{"stratum", "original", "calibrated", "ratio"}); err != nil {
+		return err
+	}
+
+	for i := range calibrated {
+		ratio := 0.0
+		if original[i] != 0 {
+			ratio = calibrated[i] / original[i]
+		}
+		rec := []string{
+			fmt.Sprintf("%d", i),
+			fmt.Sprintf("%.0f", original[i]),
+			fmt.Sprintf("%.0f", calibrated[i]),
+			fmt.Sprintf("%.4f", ratio),
+		}
+		if err := wtr.Write(rec); err != nil {
+			return err
+		}
+	}
+
+	wtr.Flush()
+	return wtr.Error()
+}
+
+// printReport writes a short diagnostic summary to stdout.
+func printReport(r calibrate.Report) {
+	fmt.Printf("design effect (Kish): %.3f\n", r.DesignEffect)
+	fmt.Println("largest weight adjustments:")
+	for _, a := range r.LargestAdjustments {
+		fmt.Printf("  stratum %2d: %.0f -> %.0f (x%.3f)\n", a.Cell, a.Original, a.Calibrated, a.Ratio)
+	}
+}
@@ -0,0 +1,157 @@
+// Package manifest describes one year of MEPS data as a JSON
+// descriptor, so that adding a new survey year is a matter of
+// dropping in a data directory and a manifest rather than editing
+// code.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/kshedden/MEPS-weights/parser"
+)
+
+// FileName is the conventional name of a year's manifest file within
+// its data directory.
+const FileName = "manifest.json"
+
+// Manifest describes everything needed to import one year of MEPS
+// data: where the data and layout files live, which variables carry
+// the weight and the ESI stratification fields (these drift across
+// years, e.g. PEGJA15 in 2015 vs PEGJA09 in 2009), the age cutoffs
+// used to bucket respondents, and a checksum of the raw data file.
+type Manifest struct {
+	Year int `json:"year"`
+
+	// DataFile and LayoutFile are paths relative to the manifest's
+	// own directory.
+	DataFile   string `json:"data_file"`
+	LayoutFile string `json:"layout_file"`
+
+	// SHA256 is the expected hex-encoded SHA-256 checksum of DataFile.
+	SHA256 string `json:"sha256"`
+
+	WeightVar    string `json:"weight_var"`
+	InsuranceVar string `json:"insurance_var"`
+	EmprelVar    string `json:"emprel_var"`
+	RegionVar    string `json:"region_var"`
+
+	AgeOldCutoff   int `json:"age_old_cutoff"`
+	AgeMidCutoff   int `json:"age_mid_cutoff"`
+	AgeYoungCutoff int `json:"age_young_cutoff"`
+
+	// dir is the directory the manifest was loaded from, used to
+	// resolve DataFile and LayoutFile.
+	dir string
+}
+
+// Load reads and validates the manifest at manifestPath.
+func Load(manifestPath string) (*Manifest, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	m.dir = path.Dir(manifestPath)
+
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	var missing []string
+	for name, v := range map[string]string{
+		"data_file":     m.DataFile,
+		"layout_file":   m.LayoutFile,
+		"sha256":        m.SHA256,
+		"weight_var":    m.WeightVar,
+		"insurance_var": m.InsuranceVar,
+		"emprel_var":    m.EmprelVar,
+		"region_var":    m.RegionVar,
+	} {
+		if v == "" {
+			missing = append(missing, name)
+		}
+	}
+	for name, v := range map[string]int{
+		"age_old_cutoff":   m.AgeOldCutoff,
+		"age_mid_cutoff":   m.AgeMidCutoff,
+		"age_young_cutoff": m.AgeYoungCutoff,
+	} {
+		if v == 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required fields: %v", missing)
+	}
+	return nil
+}
+
+// DataPath returns the absolute path to the manifest's data file.
+func (m *Manifest) DataPath() string {
+	return path.Join(m.dir, m.DataFile)
+}
+
+// LayoutPath returns the absolute path to the manifest's SAS layout
+// file.
+func (m *Manifest) LayoutPath() string {
+	return path.Join(m.dir, m.LayoutFile)
+}
+
+// VerifyChecksum computes the SHA-256 of the manifest's data file and
+// returns an error if it does not match the manifest's declared
+// checksum.
+func (m *Manifest) VerifyChecksum() error {
+	sum, err := ChecksumFile(m.DataPath())
+	if err != nil {
+		return err
+	}
+	if sum != m.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s: manifest says %s, file is %s",
+			m.DataPath(), m.SHA256, sum)
+	}
+	return nil
+}
+
+// ChecksumFile returns the hex-encoded SHA-256 checksum of the file
+// at filePath.
+func ChecksumFile(filePath string) (string, error) {
+	fid, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer fid.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, fid); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Stratifier builds the ESIStratifier described by the manifest.
+func (m *Manifest) Stratifier() *parser.ESIStratifier {
+	return &parser.ESIStratifier{
+		InsuranceVar: m.InsuranceVar,
+		EmprelVar:    m.EmprelVar,
+		RegionVar:    m.RegionVar,
+		WeightVar:    m.WeightVar,
+		OldCutoff:    m.AgeOldCutoff,
+		MidCutoff:    m.AgeMidCutoff,
+		YoungCutoff:  m.AgeYoungCutoff,
+	}
+}
@@ -0,0 +1,136 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeManifest(t *testing.T, dir string, m Manifest) string {
+	t.Helper()
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("marshaling manifest: %v", err)
+	}
+	path := filepath.Join(dir, FileName)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("writing manifest fixture: %v", err)
+	}
+	return path
+}
+
+func validManifest() Manifest {
+	return Manifest{
+		Year:           2015,
+		DataFile:       "h181.dat.gz",
+		LayoutFile:     "h181su.txt",
+		SHA256:         "deadbeef",
+		WeightVar:      "PERWT15P",
+		InsuranceVar:   "PEGJA15",
+		EmprelVar:      "HPEJA15",
+		RegionVar:      "REGION15",
+		AgeOldCutoff:   1950,
+		AgeMidCutoff:   1970,
+		AgeYoungCutoff: 1995,
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeManifest(t, dir, validManifest())
+
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if m.Year != 2015 {
+		t.Errorf("Year = %d, want 2015", m.Year)
+	}
+	if got, want := m.DataPath(), filepath.Join(dir, "h181.dat.gz"); got != want {
+		t.Errorf("DataPath() = %q, want %q", got, want)
+	}
+	if got, want := m.LayoutPath(), filepath.Join(dir, "h181su.txt"); got != want {
+		t.Errorf("LayoutPath() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadMissingRequiredFields(t *testing.T) {
+	cases := []struct {
+		name   string
+		mutate func(*Manifest)
+	}{
+		{"empty data_file", func(m *Manifest) { m.DataFile = "" }},
+		{"empty sha256", func(m *Manifest) { m.SHA256 = "" }},
+		{"zero age_old_cutoff", func(m *Manifest) { m.AgeOldCutoff = 0 }},
+		{"zero age_mid_cutoff", func(m *Manifest) { m.AgeMidCutoff = 0 }},
+		{"zero age_young_cutoff", func(m *Manifest) { m.AgeYoungCutoff = 0 }},
+	}
+	for _, c := range cases {
+		m := validManifest()
+		c.mutate(&m)
+		dir := t.TempDir()
+		path := writeManifest(t, dir, m)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s: Load did not return an error", c.name)
+		}
+	}
+}
+
+func TestChecksumFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.txt")
+	contents := []byte("some MEPS data\n")
+	if err := os.WriteFile(path, contents, 0o644); err != nil {
+		t.Fatalf("writing data fixture: %v", err)
+	}
+
+	sum, err := ChecksumFile(path)
+	if err != nil {
+		t.Fatalf("ChecksumFile returned error: %v", err)
+	}
+	want := sha256.Sum256(contents)
+	if sum != hex.EncodeToString(want[:]) {
+		t.Errorf("ChecksumFile = %s, want %s", sum, hex.EncodeToString(want[:]))
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	contents := []byte("some MEPS data\n")
+	if err := os.WriteFile(filepath.Join(dir, "h181.dat.gz"), contents, 0o644); err != nil {
+		t.Fatalf("writing data fixture: %v", err)
+	}
+	sum := sha256.Sum256(contents)
+
+	m := validManifest()
+	m.SHA256 = hex.EncodeToString(sum[:])
+	path := writeManifest(t, dir, m)
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if err := loaded.VerifyChecksum(); err != nil {
+		t.Errorf("VerifyChecksum with matching data returned error: %v", err)
+	}
+
+	loaded.SHA256 = "0000000000000000000000000000000000000000000000000000000000000000"
+	if err := loaded.VerifyChecksum(); err == nil {
+		t.Error("VerifyChecksum with a mismatched checksum did not return an error")
+	}
+}
+
+func TestStratifier(t *testing.T) {
+	m := validManifest()
+	s := m.Stratifier()
+	if s.InsuranceVar != m.InsuranceVar || s.EmprelVar != m.EmprelVar ||
+		s.RegionVar != m.RegionVar || s.WeightVar != m.WeightVar {
+		t.Errorf("Stratifier() variable names = %+v, want matching manifest fields", s)
+	}
+	if s.OldCutoff != m.AgeOldCutoff || s.MidCutoff != m.AgeMidCutoff || s.YoungCutoff != m.AgeYoungCutoff {
+		t.Errorf("Stratifier() age cutoffs = %+v, want matching manifest fields", s)
+	}
+}
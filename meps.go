@@ -1,7 +1,8 @@
 /*
 This script obtains national totals for 32 strata of the US
 employer-sponsored insurance (ESI) population, based on the MEPS
-survey data. The main MEPS site is here:
+survey data, along with replicate-weight standard errors. The main
+MEPS site is here:
 
 https://meps.ahrq.gov/mepsweb
 
@@ -13,256 +14,334 @@ https://meps.ahrq.gov/mepsweb/data_stats/download_data_files.jsp
 
 Also download the SAS programming statements file for each year.
 
-Next, compress the data files using gzip, and place everything into a
+Next, compress the data files using gzip, and place a manifest.json
+describing the year (see manifest.Manifest) alongside them, in a
 directory layout as follows:
 
 |---2009
 |    |---h129.dat.gz
 |    |---h129su.txt
+|    |---manifest.json
 _
 |---2010
 |    |---h138.dat.gz
 |    |---h138su.txt
+|    |---manifest.json
 
-Then run this script, after changing the dr variable below to point to
-the location where the data are stored.
+Then run this script, passing -data-dir to point at the directory
+containing these year subdirectories. Adding a new year requires no
+code changes: drop in a new subdirectory with its data, layout, and
+manifest.json.
 
 The strata are numbered 0 to 32 (so there are 33 strata in all).
 Stratum 0 consists of people who are <18, >=65, or have missing data
-on any of the four variables used to define the weight stratum.
+on any of the four variables used to define the weight stratum. See
+parser.ESIStratifier for the full definition.
 
-Given a wgtkey value between 1 and 32 (inclusive), the characteristics
-of its stratum can be recovered as follows:
+Totals are written to meps_totals.csv, and replicate-weight standard
+errors and 95% confidence intervals for those totals are written to
+meps_se.csv. By default standard errors use Balanced Repeated
+Replication (BRR) over the BRR1..BRRn columns present in each year's
+layout; pass -method=fay -rho=<value> to use Fay's method instead.
 
-female = (wgtkey - 1) % 2       // 1=female, 0=male
-emprel = (wgtkey - 1) / 2 % 2   // 1=dependent, 0=policy holder
-age    = (wgtkey - 1) / 4 % 2   // 0=born after 1967, 1=born on/before 1967
-region = (wgtkey - 1) / 8       // 0=NE, 1=NC, 2=S, 3=W (census regions)
-*/
+Each year's fixed-width scan is memoized under -cache-dir, keyed by
+the data file's checksum, the layout's checksum, and the set of
+variables being extracted (see cache.Key), so re-running with the
+same strata and variance design skips straight to a gob decode.
+
+This binary has two subcommands:
 
+	meps totals [flags]      computes the totals and SEs described above
+	meps calibrate [flags]   rakes or post-stratifies totals against
+	                         external control totals; see runCalibrate.
+*/
 package main
 
 import (
-	"bufio"
-	"compress/gzip"
 	"encoding/csv"
+	"flag"
 	"fmt"
 	"os"
-	"path"
-	"strconv"
-	"strings"
-)
+	"path/filepath"
+	"sort"
 
-const (
-	// Path to all MEPS data files
-	dr string = "/nfs/kshedden/MEPS/data"
+	"github.com/kshedden/MEPS-weights/cache"
+	"github.com/kshedden/MEPS-weights/manifest"
+	"github.com/kshedden/MEPS-weights/parser"
+	"github.com/kshedden/MEPS-weights/variance"
 )
 
 var (
-	// Used to construct MEPS file names, each year has a 3-digit code number.
-	yrnum = map[int]int{2009: 129, 2010: 138, 2011: 147, 2012: 155, 2013: 163,
-		2014: 171, 2015: 174}
-
-	// Name of overall weight variable for each year.
-	wgtvar = map[int]string{2009: "PERWT09F", 2010: "PERWT10F", 2011: "PERWT11F",
-		2012: "PERWT12F", 2013: "PERWT13F", 2014: "PERWT14F", 2015: "PERWT15P"}
+	totalsCmd  = flag.NewFlagSet("totals", flag.ExitOnError)
+	dataDir    = totalsCmd.String("data-dir", "/nfs/kshedden/MEPS/data", "directory containing one subdirectory per MEPS year")
+	cacheDir   = totalsCmd.String("cache-dir", "/nfs/kshedden/MEPS/cache", "directory for cached parsed records, keyed by data/layout checksum and variable selection")
+	methodFlag = totalsCmd.String("method", "brr", "replicate variance method: brr or fay")
+	rhoFlag    = totalsCmd.Float64("rho", 0, "Fay's perturbation factor, used when -method=fay")
 )
 
-// The data are in fixed-width format.  We can obtain the variable
-// names and positions from the SAS format files.
-func getvinf(year int) map[string][2]int {
-
-	// SAS script file name and reader
-	sp := fmt.Sprintf("h%dsu.txt", yrnum[year])
-	sp = path.Join(dr, fmt.Sprintf("%d", year), sp)
-	fid, err := os.Open(sp)
+// findManifests locates every year's manifest.json under dataDir,
+// sorted by year.
+func findManifests(dataDir string) ([]*manifest.Manifest, error) {
+	paths, err := filepath.Glob(filepath.Join(dataDir, "*", manifest.FileName))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	defer fid.Close()
-	rdr := bufio.NewScanner(fid)
-
-	// Map variable names to variable description.
-	vdef := make(map[string][2]int)
-
-	// Update the variable description for one variable.
-	process := func(line string) {
-		toks := strings.Fields(line)
-		vname := toks[1]
 
-		// Process the position
-		pos := strings.TrimLeft(toks[0], "@")
-		ipos, err := strconv.Atoi(pos)
+	manifests := make([]*manifest.Manifest, 0, len(paths))
+	for _, p := range paths {
+		m, err := manifest.Load(p)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
-		ipos -= 1 // want 0-based positions
+		manifests = append(manifests, m)
+	}
 
-		// Process the width
-		w := strings.TrimLeft(toks[2], "$")
-		if strings.Contains(w, ".") {
-			toks := strings.Split(w, ".")
-			w = toks[0]
-		}
-		iw, err := strconv.Atoi(w)
-		if err != nil {
-			panic(err)
-		}
+	sort.Slice(manifests, func(i, j int) bool { return manifests[i].Year < manifests[j].Year })
 
-		vdef[vname] = [2]int{ipos, iw}
+	return manifests, nil
+}
+
+// designFor builds the replicate-weight variance design for a year,
+// discovering the BRR replicate columns actually present in layout
+// since MEPS has changed the replicate scheme across years.
+func designFor(layout *parser.SASLayout) *variance.Design {
+	method := variance.MethodBRR
+	if *methodFlag == "fay" {
+		method = variance.MethodFay
 	}
 
-	// Skip the initial section
-	for rdr.Scan() {
-		line := rdr.Text()
-		if strings.Contains(line, "INPUT @1") {
-			process(line[5:len(line)])
-			break
-		}
+	return &variance.Design{
+		ReplicateVars: layout.VarsWithPrefix("BRR"),
+		Method:        method,
+		Rho:           *rhoFlag,
 	}
-	if err := rdr.Err(); err != nil {
-		panic(err)
+}
+
+// loadRecords returns the filtered records for m's data file, limited
+// to vars. Records are served from store when a cache entry exists
+// for the current (data checksum, layout checksum, vars) combination;
+// otherwise they are parsed from the fixed-width file and the result
+// is cached for next time.
+func loadRecords(m *manifest.Manifest, layout *parser.SASLayout, vars []string, store *cache.Store) ([]parser.Record, error) {
+	layoutChecksum, err := manifest.ChecksumFile(m.LayoutPath())
+	if err != nil {
+		return nil, err
+	}
+	key := cache.Key(m.SHA256, layoutChecksum, vars)
+
+	if recs, ok, err := store.Load(key); err != nil {
+		return nil, err
+	} else if ok {
+		return recs, nil
 	}
 
-	for rdr.Scan() {
-		line := rdr.Text()
+	hid, err := os.Open(m.DataPath())
+	if err != nil {
+		return nil, err
+	}
+	defer hid.Close()
+
+	rdr, err := parser.NewFixedWidthReader(hid, layout, vars)
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
 
-		if strings.HasPrefix(line, ";") {
-			// Reached end of section of interest
+	var recs []parser.Record
+	for {
+		rec, ok := rdr.Next()
+		if !ok {
 			break
 		}
-
-		process(line[5:len(line)])
+		recs = append(recs, rec)
 	}
 	if err := rdr.Err(); err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	if err := store.Save(key, recs); err != nil {
+		return nil, err
 	}
 
-	return vdef
+	return recs, nil
 }
 
-func getpopw(year int) []float64 {
+// getpopw sums the weight variable, and each replicate weight in the
+// resulting design, within each stratum for one year of MEPS data.
+func getpopw(m *manifest.Manifest, store *cache.Store) (totals []float64, design *variance.Design, repTotals [][]float64, err error) {
+	if err := m.VerifyChecksum(); err != nil {
+		return nil, nil, nil, err
+	}
 
-	vdef := getvinf(year)
+	layout, err := parser.ParseSASLayout(m.LayoutPath())
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-	// 2 digit year, as string
-	ys := fmt.Sprintf("%d", year)[2:]
+	strat := m.Stratifier()
+	if err := layout.RequireVars(strat.Vars()); err != nil {
+		return nil, nil, nil, fmt.Errorf("year %d: %w", m.Year, err)
+	}
 
-	// The ESI population total by stratum
-	sampsize := make([]float64, 33)
+	design = designFor(layout)
 
-	dpath := fmt.Sprintf("h%d.dat.gz", yrnum[year])
-	dpath = path.Join(dr, fmt.Sprintf("%d", year), dpath)
-	hid, err := os.Open(dpath)
-	if err != err {
-		panic(err)
-	}
-	defer hid.Close()
-	fid, err := gzip.NewReader(hid)
+	vars := append(append([]string{}, strat.Vars()...), design.ReplicateVars...)
+	recs, err := loadRecords(m, layout, vars, store)
 	if err != nil {
-		panic(err)
-	}
-	scanner := bufio.NewScanner(fid)
-
-	// Process one record
-	ext := func(line string, vname string) float64 {
-		u := vdef[vname]
-		start := u[0]
-		end := u[0] + u[1]
-		x, err := strconv.ParseFloat(line[start:end], 64)
-		if err != nil {
-			panic(err)
-		}
-		return x
+		return nil, nil, nil, err
 	}
 
-	for scanner.Scan() {
-
-		line := scanner.Text()
+	totals = make([]float64, strat.NumStrata())
+	repTotals = make([][]float64, strat.NumStrata())
+	for i := range repTotals {
+		repTotals[i] = make([]float64, len(design.ReplicateVars))
+	}
 
-		insur := ext(line, "PEGJA"+ys)
-		if insur != 1 {
+	for _, rec := range recs {
+		stratum, ok := strat.Stratum(rec)
+		if !ok {
 			continue
 		}
 
-		dobyy := ext(line, "DOBYY")
-		region := ext(line, "REGION"+ys)
-		wgt := ext(line, wgtvar[year])
-		emprel := ext(line, "HPEJA"+ys)
-
-		female := ext(line, "SEX")
-		if female != 1 && female != 2 {
-			continue
+		totals[stratum] += rec[m.WeightVar]
+		for k, rv := range design.ReplicateVars {
+			repTotals[stratum][k] += rec[rv]
 		}
-		female -= 1
+	}
 
-		// age stratum
-		var age float64
-		switch {
-		case dobyy < 2009-65:
-			continue // too old
-		case dobyy < 2012-45:
-			age = 1
-		case dobyy < 2012-18:
-			age = 0
-		default:
-			continue // too young
-		}
+	return totals, design, repTotals, nil
+}
 
-		// region
-		if region < 0 {
-			continue
-		}
-		region -= 1
+func writeTotals(years []int, dw map[int][]float64, numStrata int) {
+	fid, err := os.Create("meps_totals.csv")
+	if err != nil {
+		panic(err)
+	}
+	defer fid.Close()
+	wtr := csv.NewWriter(fid)
 
-		// emprel
-		if emprel == -1 {
-			continue
+	recs := make([]string, len(years))
+
+	for i, y := range years {
+		recs[i] = fmt.Sprintf("%d", y)
+	}
+	wtr.Write(recs)
+
+	for i := 0; i < numStrata; i++ {
+		for j, y := range years {
+			recs[j] = fmt.Sprintf("%.0f", dw[y][i])
+		}
+		if err := wtr.Write(recs); err != nil {
+			panic(err)
 		}
-		emprel -= 1
+	}
 
-		stratum := int(1 + female + 2*emprel + 4*age + 8*region)
+	wtr.Flush()
+}
 
-		sampsize[stratum] += wgt
+// writeSEs writes per-stratum, per-year replicate standard errors and
+// 95% confidence intervals, one row per (year, stratum) combination.
+func writeSEs(years []int, dw map[int][]float64, designs map[int]*variance.Design, repTotals map[int][][]float64, numStrata int) {
+	fid, err := os.Create("meps_se.csv")
+	if err != nil {
+		panic(err)
 	}
+	defer fid.Close()
+	wtr := csv.NewWriter(fid)
 
-	if err := scanner.Err(); err != nil {
+	if err := wtr.Write([]string{"year", "stratum", "total", "se", "ci_lo", "ci_hi"}); err != nil {
 		panic(err)
 	}
 
-	return sampsize
+	for _, y := range years {
+		design := designs[y]
+		for i := 0; i < numStrata; i++ {
+			total := dw[y][i]
+			se, err := design.StandardError(total, repTotals[y][i])
+			if err != nil {
+				panic(err)
+			}
+			lo, hi := variance.ConfidenceInterval(total, se)
+			rec := []string{
+				fmt.Sprintf("%d", y),
+				fmt.Sprintf("%d", i),
+				fmt.Sprintf("%.0f", total),
+				fmt.Sprintf("%.2f", se),
+				fmt.Sprintf("%.0f", lo),
+				fmt.Sprintf("%.0f", hi),
+			}
+			if err := wtr.Write(rec); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	wtr.Flush()
 }
 
 func main() {
-	dw := make(map[int][]float64)
-	for _, y := range []int{2009, 2010, 2011, 2012, 2013, 2014, 2015} {
-		ss := getpopw(y)
-		dw[y] = ss
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: meps <totals|calibrate> [flags]")
+		os.Exit(2)
 	}
 
-	fid, err := os.Create("meps_totals.csv")
+	switch os.Args[1] {
+	case "totals":
+		runTotals(os.Args[2:])
+	case "calibrate":
+		runCalibrate(os.Args[2:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown subcommand %q, want %q or %q\n", os.Args[1], "totals", "calibrate")
+		os.Exit(2)
+	}
+}
+
+func runTotals(args []string) {
+	totalsCmd.Parse(args)
+
+	if *methodFlag == "fay" && (*rhoFlag < 0 || *rhoFlag >= 1) {
+		fmt.Fprintf(os.Stderr, "totals: -rho must be in [0, 1) for -method=fay, got %v\n", *rhoFlag)
+		os.Exit(2)
+	}
+
+	manifests, err := findManifests(*dataDir)
 	if err != nil {
 		panic(err)
 	}
-	defer fid.Close()
-	wtr := csv.NewWriter(fid)
+	if len(manifests) == 0 {
+		panic(fmt.Sprintf("no %s found under %s", manifest.FileName, *dataDir))
+	}
 
-	recs := make([]string, 7)
+	store := cache.NewStore(*cacheDir)
 
-	for i := 0; i < 7; i++ {
-		recs[i] = fmt.Sprintf("%d", 2009+i)
-	}
-	wtr.Write(recs)
+	years := make([]int, len(manifests))
+	dw := make(map[int][]float64)
+	designs := make(map[int]*variance.Design)
+	repTotals := make(map[int][][]float64)
+	numStrata := 0
 
-	for i := 0; i < 33; i++ {
-		for j := 0; j < 7; j++ {
-			recs[j] = fmt.Sprintf("%.0f", dw[2009+j][i])
-		}
-		err := wtr.Write(recs)
+	for i, m := range manifests {
+		years[i] = m.Year
+
+		totals, design, reps, err := getpopw(m, store)
 		if err != nil {
 			panic(err)
 		}
+
+		switch {
+		case numStrata == 0:
+			numStrata = len(totals)
+		case len(totals) != numStrata:
+			panic(fmt.Sprintf("year %d has %d strata (from its Stratifier.NumStrata()), but year %d has %d; "+
+				"all years must share the same stratification to be written to one CSV",
+				m.Year, len(totals), years[0], numStrata))
+		}
+
+		dw[m.Year] = totals
+		designs[m.Year] = design
+		repTotals[m.Year] = reps
 	}
 
-	wtr.Flush()
+	writeTotals(years, dw, numStrata)
+	writeSEs(years, dw, designs, repTotals, numStrata)
 }
@@ -0,0 +1,156 @@
+// Package parser provides a reusable reader for MEPS fixed-width data
+// files, driven by the SAS programming statements file that MEPS
+// distributes alongside each year's data.
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VarSpec describes the location of one variable within a fixed-width
+// record: a 0-based start offset and a width, both in bytes.
+type VarSpec struct {
+	Start int
+	Width int
+}
+
+// SASLayout holds the fixed-width layout of a MEPS data file, as
+// parsed from its accompanying SAS programming statements file
+// (conventionally named h<code>su.txt).
+type SASLayout struct {
+	Vars map[string]VarSpec
+}
+
+// Pos returns the start and end (exclusive) byte offsets of vname
+// within a record, and whether vname is present in the layout.
+func (s *SASLayout) Pos(vname string) (start, end int, ok bool) {
+	v, ok := s.Vars[vname]
+	if !ok {
+		return 0, 0, false
+	}
+	return v.Start, v.Start + v.Width, true
+}
+
+// VarsWithPrefix returns the variables in the layout whose name
+// starts with prefix followed by an integer (e.g. prefix "BRR" matches
+// "BRR1".."BRR128"), sorted by that integer. It is used to discover
+// replicate weight columns, whose count varies across MEPS years.
+func (s *SASLayout) VarsWithPrefix(prefix string) []string {
+	type numbered struct {
+		name string
+		n    int
+	}
+	var matches []numbered
+	for vname := range s.Vars {
+		if !strings.HasPrefix(vname, prefix) {
+			continue
+		}
+		n, err := strconv.Atoi(vname[len(prefix):])
+		if err != nil {
+			continue
+		}
+		matches = append(matches, numbered{vname, n})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].n < matches[j].n })
+
+	out := make([]string, len(matches))
+	for i, m := range matches {
+		out[i] = m.name
+	}
+	return out
+}
+
+// RequireVars returns an error naming every variable in vars that is
+// not present in the layout, so a caller can fail loudly instead of
+// panicking deep inside a scan over missing data.
+func (s *SASLayout) RequireVars(vars []string) error {
+	var missing []string
+	for _, v := range vars {
+		if _, ok := s.Vars[v]; !ok {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("variables not found in SAS layout: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ParseSASLayout reads the SAS programming statements file at path and
+// returns the variable layout declared in its "INPUT @1" section.
+func ParseSASLayout(path string) (*SASLayout, error) {
+	fid, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fid.Close()
+	rdr := bufio.NewScanner(fid)
+
+	vars := make(map[string]VarSpec)
+
+	process := func(line string) error {
+		toks := strings.Fields(line)
+		vname := toks[1]
+
+		// Process the position.
+		pos := strings.TrimLeft(toks[0], "@")
+		ipos, err := strconv.Atoi(pos)
+		if err != nil {
+			return fmt.Errorf("parsing position for %s: %w", vname, err)
+		}
+		ipos-- // want 0-based positions
+
+		// Process the width.
+		w := strings.TrimLeft(toks[2], "$")
+		if strings.Contains(w, ".") {
+			w = strings.Split(w, ".")[0]
+		}
+		iw, err := strconv.Atoi(w)
+		if err != nil {
+			return fmt.Errorf("parsing width for %s: %w", vname, err)
+		}
+
+		vars[vname] = VarSpec{Start: ipos, Width: iw}
+		return nil
+	}
+
+	// Skip the initial section.
+	found := false
+	for rdr.Scan() {
+		line := rdr.Text()
+		if strings.Contains(line, "INPUT @1") {
+			if err := process(line[5:]); err != nil {
+				return nil, err
+			}
+			found = true
+			break
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: no INPUT @1 section found", path)
+	}
+
+	for rdr.Scan() {
+		line := rdr.Text()
+		if strings.HasPrefix(line, ";") {
+			// Reached end of section of interest.
+			break
+		}
+		if err := process(line[5:]); err != nil {
+			return nil, err
+		}
+	}
+	if err := rdr.Err(); err != nil {
+		return nil, err
+	}
+
+	return &SASLayout{Vars: vars}, nil
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeLayoutFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "layout.txt")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("writing layout fixture: %v", err)
+	}
+	return path
+}
+
+const sampleLayout = `INPUT @1 DUPERSID $10.
+      @11 AGE 3.
+      @14 BRR2 4.
+      @18 BRR1 4.
+;
+`
+
+func TestParseSASLayout(t *testing.T) {
+	layout, err := ParseSASLayout(writeLayoutFile(t, sampleLayout))
+	if err != nil {
+		t.Fatalf("ParseSASLayout returned error: %v", err)
+	}
+
+	cases := []struct {
+		vname      string
+		start, end int
+	}{
+		{"DUPERSID", 0, 10},
+		{"AGE", 10, 13},
+		{"BRR2", 13, 17},
+		{"BRR1", 17, 21},
+	}
+	for _, c := range cases {
+		start, end, ok := layout.Pos(c.vname)
+		if !ok {
+			t.Errorf("Pos(%q): not found", c.vname)
+			continue
+		}
+		if start != c.start || end != c.end {
+			t.Errorf("Pos(%q) = (%d, %d), want (%d, %d)", c.vname, start, end, c.start, c.end)
+		}
+	}
+
+	if _, _, ok := layout.Pos("NOPE"); ok {
+		t.Error("Pos(\"NOPE\") reported ok=true, want false")
+	}
+}
+
+func TestParseSASLayoutNoInputSection(t *testing.T) {
+	_, err := ParseSASLayout(writeLayoutFile(t, "; nothing here\n"))
+	if err == nil {
+		t.Fatal("ParseSASLayout with no INPUT @1 section did not return an error")
+	}
+}
+
+func TestVarsWithPrefix(t *testing.T) {
+	layout, err := ParseSASLayout(writeLayoutFile(t, sampleLayout))
+	if err != nil {
+		t.Fatalf("ParseSASLayout returned error: %v", err)
+	}
+
+	got := layout.VarsWithPrefix("BRR")
+	want := []string{"BRR1", "BRR2"}
+	if len(got) != len(want) {
+		t.Fatalf("VarsWithPrefix(\"BRR\") = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("VarsWithPrefix(\"BRR\")[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRequireVars(t *testing.T) {
+	layout, err := ParseSASLayout(writeLayoutFile(t, sampleLayout))
+	if err != nil {
+		t.Fatalf("ParseSASLayout returned error: %v", err)
+	}
+
+	if err := layout.RequireVars([]string{"DUPERSID", "AGE"}); err != nil {
+		t.Errorf("RequireVars with present vars returned error: %v", err)
+	}
+	if err := layout.RequireVars([]string{"DUPERSID", "NOPE"}); err == nil {
+		t.Error("RequireVars with a missing var did not return an error")
+	}
+}
@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Record holds the values of the requested variables for a single
+// fixed-width row, keyed by variable name.
+type Record map[string]float64
+
+// FixedWidthReader streams typed records out of a gzip'd MEPS
+// fixed-width data file, extracting only the variables it is asked
+// for.
+type FixedWidthReader struct {
+	scanner *bufio.Scanner
+	gz      *gzip.Reader
+	layout  *SASLayout
+	vars    []string
+	err     error
+}
+
+// NewFixedWidthReader returns a reader that scans the gzip stream r
+// using layout, yielding a Record with values for vars on each call
+// to Next. vars must all be present in layout.
+func NewFixedWidthReader(r io.Reader, layout *SASLayout, vars []string) (*FixedWidthReader, error) {
+	for _, v := range vars {
+		if _, ok := layout.Vars[v]; !ok {
+			return nil, fmt.Errorf("variable %s is not present in the SAS layout", v)
+		}
+	}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FixedWidthReader{
+		scanner: bufio.NewScanner(gz),
+		gz:      gz,
+		layout:  layout,
+		vars:    vars,
+	}, nil
+}
+
+// Next scans the next record and reports whether one was found. It
+// returns false at end of stream or on error; call Err to
+// distinguish the two.
+func (r *FixedWidthReader) Next() (Record, bool) {
+	if r.err != nil || !r.scanner.Scan() {
+		return nil, false
+	}
+
+	line := r.scanner.Text()
+	rec := make(Record, len(r.vars))
+	for _, vname := range r.vars {
+		start, end, _ := r.layout.Pos(vname)
+		x, err := strconv.ParseFloat(line[start:end], 64)
+		if err != nil {
+			r.err = fmt.Errorf("parsing %s: %w", vname, err)
+			return nil, false
+		}
+		rec[vname] = x
+	}
+
+	return rec, true
+}
+
+// Err returns the first error encountered while scanning, including
+// any field-parsing failure, or nil if the stream was fully consumed.
+func (r *FixedWidthReader) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.scanner.Err()
+}
+
+// Close releases the underlying gzip reader.
+func (r *FixedWidthReader) Close() error {
+	return r.gz.Close()
+}
@@ -0,0 +1,81 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+)
+
+func gzipLines(t *testing.T, lines ...string) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(strings.Join(lines, "\n") + "\n")); err != nil {
+		t.Fatalf("writing gzip fixture: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("closing gzip fixture: %v", err)
+	}
+	return &buf
+}
+
+func testLayout() *SASLayout {
+	return &SASLayout{Vars: map[string]VarSpec{
+		"A": {Start: 0, Width: 3},
+		"B": {Start: 3, Width: 2},
+	}}
+}
+
+func TestNewFixedWidthReaderMissingVar(t *testing.T) {
+	_, err := NewFixedWidthReader(gzipLines(t, "12345"), testLayout(), []string{"NOPE"})
+	if err == nil {
+		t.Fatal("NewFixedWidthReader with an unknown variable did not return an error")
+	}
+}
+
+func TestFixedWidthReaderNext(t *testing.T) {
+	r, err := NewFixedWidthReader(gzipLines(t, "12345", "67890"), testLayout(), []string{"A", "B"})
+	if err != nil {
+		t.Fatalf("NewFixedWidthReader returned error: %v", err)
+	}
+	defer r.Close()
+
+	rec, ok := r.Next()
+	if !ok {
+		t.Fatalf("Next() returned ok=false on first record, Err=%v", r.Err())
+	}
+	if rec["A"] != 123 || rec["B"] != 45 {
+		t.Errorf("first record = %v, want A=123 B=45", rec)
+	}
+
+	rec, ok = r.Next()
+	if !ok {
+		t.Fatalf("Next() returned ok=false on second record, Err=%v", r.Err())
+	}
+	if rec["A"] != 678 || rec["B"] != 90 {
+		t.Errorf("second record = %v, want A=678 B=90", rec)
+	}
+
+	if _, ok := r.Next(); ok {
+		t.Error("Next() returned ok=true past end of stream")
+	}
+	if err := r.Err(); err != nil {
+		t.Errorf("Err() after clean end of stream = %v, want nil", err)
+	}
+}
+
+func TestFixedWidthReaderParseError(t *testing.T) {
+	r, err := NewFixedWidthReader(gzipLines(t, "abXYZ"), testLayout(), []string{"A", "B"})
+	if err != nil {
+		t.Fatalf("NewFixedWidthReader returned error: %v", err)
+	}
+	defer r.Close()
+
+	if _, ok := r.Next(); ok {
+		t.Fatal("Next() returned ok=true for an unparseable field")
+	}
+	if r.Err() == nil {
+		t.Error("Err() after a parse failure = nil, want non-nil")
+	}
+}
@@ -0,0 +1,94 @@
+package parser
+
+// Stratifier assigns records to strata. Implementations declare the
+// variables they need via Vars, and NumStrata declares the size of
+// the per-stratum totals slice a caller should allocate; stratum
+// indices returned by Stratum must fall in [0, NumStrata).
+type Stratifier interface {
+	// Vars returns the names of the variables this Stratifier reads
+	// from each record.
+	Vars() []string
+
+	// NumStrata returns the number of strata, including any reserved
+	// "excluded" stratum.
+	NumStrata() int
+
+	// Stratum returns the stratum index for rec, and false if rec
+	// does not belong to any stratum and should be dropped.
+	Stratum(rec Record) (int, bool)
+}
+
+// ESIStratifier implements the MEPS employer-sponsored insurance (ESI)
+// stratification: 32 cells defined by sex, policy-holder/dependent
+// status, an age split, and census region, plus a reserved stratum 0
+// for everyone else. Given a wgtkey value between 1 and 32 inclusive,
+// the characteristics of its stratum can be recovered as follows:
+//
+//	female = (wgtkey - 1) % 2       // 1=female, 0=male
+//	emprel = (wgtkey - 1) / 2 % 2   // 1=dependent, 0=policy holder
+//	age    = (wgtkey - 1) / 4 % 2   // 0=born after OldCutoff, 1=born on/before
+//	region = (wgtkey - 1) / 8       // 0=NE, 1=NC, 2=S, 3=W (census regions)
+type ESIStratifier struct {
+	// Names of the MEPS variables that drift across years.
+	InsuranceVar string // e.g. PEGJA15
+	EmprelVar    string // e.g. HPEJA15
+	RegionVar    string // e.g. REGION15
+	WeightVar    string // e.g. PERWT15P
+
+	// Birth-year cutoffs used to bucket respondents into the two age
+	// cells and to drop those outside the 18-64 ESI population.
+	OldCutoff   int // respondents born before this year are too old
+	MidCutoff   int // splits the two in-range age cells
+	YoungCutoff int // respondents born on/after this year are too young
+}
+
+// Vars implements Stratifier.
+func (s *ESIStratifier) Vars() []string {
+	return []string{s.InsuranceVar, "DOBYY", s.RegionVar, s.WeightVar, s.EmprelVar, "SEX"}
+}
+
+// NumStrata implements Stratifier.
+func (s *ESIStratifier) NumStrata() int {
+	return 33
+}
+
+// Stratum implements Stratifier.
+func (s *ESIStratifier) Stratum(rec Record) (int, bool) {
+	if rec[s.InsuranceVar] != 1 {
+		return 0, false
+	}
+
+	dobyy := rec["DOBYY"]
+	region := rec[s.RegionVar]
+	emprel := rec[s.EmprelVar]
+
+	female := rec["SEX"]
+	if female != 1 && female != 2 {
+		return 0, false
+	}
+	female--
+
+	var age float64
+	switch {
+	case dobyy < float64(s.OldCutoff):
+		return 0, false // too old
+	case dobyy < float64(s.MidCutoff):
+		age = 1
+	case dobyy < float64(s.YoungCutoff):
+		age = 0
+	default:
+		return 0, false // too young
+	}
+
+	if region < 0 {
+		return 0, false
+	}
+	region--
+
+	if emprel == -1 {
+		return 0, false
+	}
+	emprel--
+
+	return int(1 + female + 2*emprel + 4*age + 8*region), true
+}
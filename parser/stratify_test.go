@@ -0,0 +1,88 @@
+package parser
+
+import "testing"
+
+func testStratifier() *ESIStratifier {
+	return &ESIStratifier{
+		InsuranceVar: "INS",
+		EmprelVar:    "EMPREL",
+		RegionVar:    "REGION",
+		WeightVar:    "WEIGHT",
+		OldCutoff:    1950,
+		MidCutoff:    1970,
+		YoungCutoff:  1995,
+	}
+}
+
+func TestESIStratifierNumStrata(t *testing.T) {
+	if n := testStratifier().NumStrata(); n != 33 {
+		t.Errorf("NumStrata() = %d, want 33", n)
+	}
+}
+
+func TestESIStratifierVars(t *testing.T) {
+	want := []string{"INS", "DOBYY", "REGION", "WEIGHT", "EMPREL", "SEX"}
+	got := testStratifier().Vars()
+	if len(got) != len(want) {
+		t.Fatalf("Vars() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Vars()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestESIStratifierStratum(t *testing.T) {
+	s := testStratifier()
+
+	// female=1(->0), emprel=1(->0), dobyy=1980 is in [MidCutoff,YoungCutoff) so age=0,
+	// region=1(->0): stratum = 1 + 0 + 2*0 + 4*0 + 8*0 = 1.
+	rec := Record{"INS": 1, "SEX": 1, "EMPREL": 1, "DOBYY": 1980, "REGION": 1}
+	got, ok := s.Stratum(rec)
+	if !ok || got != 1 {
+		t.Errorf("Stratum(%v) = (%d, %v), want (1, true)", rec, got, ok)
+	}
+
+	// female=2(->1), emprel=2(->1), dobyy=1960 is in [OldCutoff,MidCutoff) so age=1,
+	// region=2(->1): stratum = 1 + 1 + 2*1 + 4*1 + 8*1 = 16.
+	rec = Record{"INS": 1, "SEX": 2, "EMPREL": 2, "DOBYY": 1960, "REGION": 2}
+	got, ok = s.Stratum(rec)
+	if !ok || got != 16 {
+		t.Errorf("Stratum(%v) = (%d, %v), want (16, true)", rec, got, ok)
+	}
+
+	// dobyy exactly at OldCutoff is not "too old" (strict <), so it falls in the
+	// older in-range age bucket rather than being dropped.
+	rec = Record{"INS": 1, "SEX": 1, "EMPREL": 1, "DOBYY": 1950, "REGION": 1}
+	if _, ok := s.Stratum(rec); !ok {
+		t.Error("Stratum() with DOBYY == OldCutoff was dropped, want kept")
+	}
+}
+
+func TestESIStratifierDrops(t *testing.T) {
+	s := testStratifier()
+	base := Record{"INS": 1, "SEX": 1, "EMPREL": 1, "DOBYY": 1980, "REGION": 1}
+
+	cases := []struct {
+		name string
+		mod  func(Record)
+	}{
+		{"not insured", func(r Record) { r["INS"] = 2 }},
+		{"bad sex code", func(r Record) { r["SEX"] = 9 }},
+		{"too old", func(r Record) { r["DOBYY"] = 1900 }},
+		{"too young", func(r Record) { r["DOBYY"] = 2000 }},
+		{"missing region", func(r Record) { r["REGION"] = -1 }},
+		{"missing emprel", func(r Record) { r["EMPREL"] = -1 }},
+	}
+	for _, c := range cases {
+		rec := Record{}
+		for k, v := range base {
+			rec[k] = v
+		}
+		c.mod(rec)
+		if _, ok := s.Stratum(rec); ok {
+			t.Errorf("%s: Stratum(%v) reported ok=true, want dropped", c.name, rec)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Package variance estimates sampling variance for MEPS survey
+// totals using the replicate weights MEPS distributes alongside its
+// primary weight (BRR, or Fay's perturbed variant).
+package variance
+
+import (
+	"fmt"
+	"math"
+)
+
+// Method identifies a replicate-weight variance estimation method.
+type Method int
+
+const (
+	// MethodBRR is standard Balanced Repeated Replication.
+	MethodBRR Method = iota
+	// MethodFay is Fay's method, a perturbed variant of BRR that
+	// down-weights the replicate/full-sample contrast by Rho.
+	MethodFay
+)
+
+// Design describes a replicate-weight variance design: which
+// replicate weight variables to sum per stratum, and how to combine
+// the resulting replicate totals into a standard error. A Design is
+// specific to one MEPS year, since the set of replicate weight
+// variables (and occasionally the method) can change across years.
+type Design struct {
+	// ReplicateVars holds the replicate weight variable names to sum,
+	// e.g. "BRR1".."BRR128".
+	ReplicateVars []string
+
+	// Method selects the combining formula used by StandardError.
+	Method Method
+
+	// Rho is Fay's perturbation factor, required when Method is
+	// MethodFay; must be in [0, 1).
+	Rho float64
+}
+
+// StandardError computes the replication standard error of total,
+// given the same quantity re-estimated from each of the design's
+// replicate weights. It returns an error if d.Method is MethodFay and
+// d.Rho is outside [0, 1), since that would otherwise divide by zero
+// or negate the denominator and silently produce an Inf or NaN SE.
+//
+//	BRR:  SE = sqrt( (1/R) * sum_r (T_r - T)^2 )
+//	Fay:  SE = sqrt( (1/(R*(1-Rho)^2)) * sum_r (T_r - T)^2 )
+func (d *Design) StandardError(total float64, repTotals []float64) (float64, error) {
+	if d.Method == MethodFay && (d.Rho < 0 || d.Rho >= 1) {
+		return 0, fmt.Errorf("variance: Rho must be in [0, 1) for Fay's method, got %v", d.Rho)
+	}
+
+	r := float64(len(repTotals))
+	if r == 0 {
+		return 0, nil
+	}
+
+	var ss float64
+	for _, rt := range repTotals {
+		diff := rt - total
+		ss += diff * diff
+	}
+
+	switch d.Method {
+	case MethodFay:
+		denom := r * (1 - d.Rho) * (1 - d.Rho)
+		return math.Sqrt(ss / denom), nil
+	default:
+		return math.Sqrt(ss / r), nil
+	}
+}
+
+// z95 is the standard normal quantile used for a 95% Wald interval.
+const z95 = 1.96
+
+// ConfidenceInterval returns the 95% confidence interval for total
+// given its standard error se.
+func ConfidenceInterval(total, se float64) (lo, hi float64) {
+	return total - z95*se, total + z95*se
+}
@@ -0,0 +1,62 @@
+package variance
+
+import (
+	"math"
+	"testing"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestStandardErrorBRR(t *testing.T) {
+	d := &Design{Method: MethodBRR}
+	se, err := d.StandardError(100, []float64{90, 110, 95, 105})
+	if err != nil {
+		t.Fatalf("StandardError returned error: %v", err)
+	}
+	want := 7.905694150420948 // sqrt((10^2+10^2+5^2+5^2)/4)
+	if !approxEqual(se, want) {
+		t.Errorf("StandardError(BRR) = %v, want %v", se, want)
+	}
+}
+
+func TestStandardErrorFay(t *testing.T) {
+	d := &Design{Method: MethodFay, Rho: 0.3}
+	se, err := d.StandardError(100, []float64{90, 110, 95, 105})
+	if err != nil {
+		t.Fatalf("StandardError returned error: %v", err)
+	}
+	want := 11.29384878631564 // sqrt(250 / (4*0.7^2))
+	if !approxEqual(se, want) {
+		t.Errorf("StandardError(Fay) = %v, want %v", se, want)
+	}
+}
+
+func TestStandardErrorNoReplicates(t *testing.T) {
+	d := &Design{Method: MethodBRR}
+	se, err := d.StandardError(100, nil)
+	if err != nil {
+		t.Fatalf("StandardError returned error: %v", err)
+	}
+	if se != 0 {
+		t.Errorf("StandardError with no replicates = %v, want 0", se)
+	}
+}
+
+func TestStandardErrorFayRhoOutOfRange(t *testing.T) {
+	for _, rho := range []float64{-0.1, 1, 1.5} {
+		d := &Design{Method: MethodFay, Rho: rho}
+		if _, err := d.StandardError(100, []float64{90, 110}); err == nil {
+			t.Errorf("StandardError with Rho=%v did not return an error", rho)
+		}
+	}
+}
+
+func TestConfidenceInterval(t *testing.T) {
+	lo, hi := ConfidenceInterval(100, 7.905694150420948)
+	wantLo, wantHi := 84.50483946517494, 115.49516053482506
+	if !approxEqual(lo, wantLo) || !approxEqual(hi, wantHi) {
+		t.Errorf("ConfidenceInterval = (%v, %v), want (%v, %v)", lo, hi, wantLo, wantHi)
+	}
+}